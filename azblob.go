@@ -0,0 +1,227 @@
+package storage_s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/bamgoo/storage"
+)
+
+type (
+	// azblobDriver backs the "azblob" registration. Azure Blob Storage
+	// isn't S3-compatible the way b2/gcs are, so it gets its own client
+	// and connection type instead of reusing s3Connection; it still
+	// implements the same storage.Connection interface and shares
+	// objectPath/tempStream with it.
+	azblobDriver struct{}
+
+	azblobConnection struct {
+		instance *storage.Instance
+		setting  azblobSetting
+		client   *azblob.Client
+	}
+
+	azblobSetting struct {
+		Account    string
+		AccountKey string
+		Container  string
+		Endpoint   string
+	}
+)
+
+// AzblobDriver returns a driver for Azure Blob Storage. access/secret are
+// the storage account name and account key; bucket selects the container.
+func AzblobDriver() storage.Driver {
+	return &azblobDriver{}
+}
+
+func (d *azblobDriver) Connect(instance *storage.Instance) (storage.Connection, error) {
+	setting := azblobSetting{Container: "default"}
+	if v, ok := instance.Setting["bucket"].(string); ok && v != "" {
+		setting.Container = v
+	}
+	if v, ok := instance.Setting["access"].(string); ok && v != "" {
+		setting.Account = v
+	}
+	if v, ok := instance.Setting["secret"].(string); ok && v != "" {
+		setting.AccountKey = v
+	}
+	if v, ok := instance.Setting["endpoint"].(string); ok && v != "" {
+		setting.Endpoint = v
+	}
+	return &azblobConnection{instance: instance, setting: setting}, nil
+}
+
+func (c *azblobConnection) Open() error {
+	if c.setting.Account == "" || c.setting.AccountKey == "" {
+		return errors.New("azblob: missing access/secret credentials")
+	}
+	cred, err := azblob.NewSharedKeyCredential(c.setting.Account, c.setting.AccountKey)
+	if err != nil {
+		return err
+	}
+	endpoint := c.setting.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net/", c.setting.Account)
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(endpoint, cred, nil)
+	if err != nil {
+		return err
+	}
+	c.client = client
+
+	ctx := context.Background()
+	containers := client.ServiceClient().NewContainerClient(c.setting.Container)
+	if _, err := containers.GetProperties(ctx, nil); err != nil {
+		if !bloberror.HasCode(err, bloberror.ContainerNotFound) {
+			return err
+		}
+		if _, err := client.CreateContainer(ctx, c.setting.Container, nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *azblobConnection) Health() storage.Health {
+	if c.client == nil {
+		return storage.Health{Workload: 1}
+	}
+	return storage.Health{Workload: 0}
+}
+
+func (c *azblobConnection) Close() error {
+	c.client = nil
+	return nil
+}
+
+func (c *azblobConnection) Upload(original string, opt storage.UploadOption) (*storage.File, error) {
+	if c.client == nil {
+		return nil, errors.New("azblob client not ready")
+	}
+	st, err := os.Stat(original)
+	if err != nil {
+		return nil, err
+	}
+	if st.IsDir() {
+		return nil, errors.New("directory upload not supported")
+	}
+	if opt.Key == "" {
+		return nil, errors.New("missing upload key")
+	}
+
+	ext := path.Ext(original)
+	if len(ext) > 0 {
+		ext = ext[1:]
+	}
+	file := c.instance.NewFile(opt.Prefix, opt.Key, ext, st.Size())
+	key := objectPath(file)
+
+	f, err := os.Open(original)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	uploadOpts := &azblob.UploadFileOptions{}
+	if opt.Mimetype != "" {
+		uploadOpts.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: &opt.Mimetype}
+	}
+	if _, err := c.client.UploadFile(context.Background(), c.setting.Container, key, f, uploadOpts); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *azblobConnection) Fetch(file *storage.File, opt storage.FetchOption) (storage.Stream, error) {
+	if c.client == nil {
+		return nil, errors.New("azblob client not ready")
+	}
+	downloadOpts := &azblob.DownloadStreamOptions{}
+	if opt.Start > 0 || opt.End > 0 {
+		var count int64
+		if opt.End > 0 {
+			count = opt.End - opt.Start + 1
+		}
+		downloadOpts.Range = blob.HTTPRange{Offset: opt.Start, Count: count}
+	}
+	out, err := c.client.DownloadStream(context.Background(), c.setting.Container, objectPath(file), downloadOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	tmp, err := os.CreateTemp("", "bamgoo-storage-s3-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmp, out.Body); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, 0); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &tempStream{file: tmp, path: tmp.Name()}, nil
+}
+
+func (c *azblobConnection) Download(file *storage.File, opt storage.DownloadOption) (string, error) {
+	if c.client == nil {
+		return "", errors.New("azblob client not ready")
+	}
+	if opt.Target == "" {
+		return "", errors.New("invalid target")
+	}
+	if st, err := os.Stat(opt.Target); err == nil && !st.IsDir() {
+		return opt.Target, nil
+	}
+	if err := os.MkdirAll(path.Dir(opt.Target), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(opt.Target)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := c.client.DownloadFile(context.Background(), c.setting.Container, objectPath(file), f, nil); err != nil {
+		return "", err
+	}
+	return opt.Target, nil
+}
+
+func (c *azblobConnection) Remove(file *storage.File, _ storage.RemoveOption) error {
+	if c.client == nil {
+		return errors.New("azblob client not ready")
+	}
+	_, err := c.client.DeleteBlob(context.Background(), c.setting.Container, objectPath(file), nil)
+	return err
+}
+
+func (c *azblobConnection) Browse(file *storage.File, opt storage.BrowseOption) (string, error) {
+	if c.client == nil {
+		return "", errors.New("azblob client not ready")
+	}
+	exp := opt.Expires
+	if exp <= 0 {
+		exp = time.Hour
+	}
+	blobClient := c.client.ServiceClient().NewContainerClient(c.setting.Container).NewBlobClient(objectPath(file))
+	url, err := blobClient.GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(exp), nil)
+	if err != nil {
+		return "", err
+	}
+	return url, nil
+}
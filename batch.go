@@ -0,0 +1,224 @@
+package storage_s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/bamgoo/storage"
+)
+
+// multipartCopyThreshold is the object size above which Copy falls back to
+// UploadPartCopy; CopyObject alone is rejected by S3 for objects >5GiB.
+const multipartCopyThreshold = 5 << 30
+
+// copyPartSize is the part size used by the UploadPartCopy fallback.
+const copyPartSize = 256 << 20
+
+// copySource builds the "bucket/key" value for CopyObjectInput.CopySource
+// and UploadPartCopyInput.CopySource. The AWS API requires it URL-encoded,
+// but the "/" separators between key segments must survive encoding or
+// the source is no longer found under its prefix, so each segment is
+// escaped on its own and rejoined with a literal "/".
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.QueryEscape(s)
+	}
+	return fmt.Sprintf("%s/%s", url.QueryEscape(bucket), strings.Join(segments, "/"))
+}
+
+// removeBatchMaxKeys is the largest key count DeleteObjects accepts per call.
+const removeBatchMaxKeys = 1000
+
+// chunkRanges splits [0, n) into consecutive [start, end) ranges of at
+// most size each, in order. It returns nil for n <= 0.
+func chunkRanges(n, size int) [][2]int {
+	var ranges [][2]int
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// needsMultipartCopy reports whether an object of the given size must be
+// copied via UploadPartCopy instead of a single CopyObject call.
+func needsMultipartCopy(size int64) bool {
+	return size > multipartCopyThreshold
+}
+
+// copyPartRanges splits [0, size) into consecutive inclusive byte ranges
+// of at most copyPartSize each, for use as UploadPartCopy's CopySourceRange.
+func copyPartRanges(size int64) [][2]int64 {
+	var ranges [][2]int64
+	for start := int64(0); start < size; start += copyPartSize {
+		end := start + copyPartSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		ranges = append(ranges, [2]int64{start, end})
+	}
+	return ranges
+}
+
+// RemoveError reports the failure to remove a single key as part of a RemoveBatch call.
+type RemoveError struct {
+	Key string
+	Err error
+}
+
+// RemoveBatch deletes up to 1000 objects per DeleteObjects call, chunking
+// automatically for larger inputs. It returns per-key failures alongside a
+// non-nil error only if the batch request itself could not be sent.
+func (c *s3Connection) RemoveBatch(files []*storage.File) ([]RemoveError, error) {
+	if c.client == nil {
+		return nil, errors.New("s3 client not ready")
+	}
+	var failures []RemoveError
+	for _, r := range chunkRanges(len(files), removeBatchMaxKeys) {
+		chunk := files[r[0]:r[1]]
+
+		ids := make([]types.ObjectIdentifier, len(chunk))
+		for i, file := range chunk {
+			ids[i] = types.ObjectIdentifier{Key: aws.String(objectPath(file))}
+		}
+
+		out, err := c.client.DeleteObjects(context.Background(), &s3.DeleteObjectsInput{
+			Bucket: aws.String(c.setting.Bucket),
+			Delete: &types.Delete{Objects: ids},
+		})
+		if err != nil {
+			return failures, err
+		}
+		for _, e := range out.Errors {
+			failures = append(failures, RemoveError{Key: aws.ToString(e.Key), Err: fmt.Errorf("%s: %s", aws.ToString(e.Code), aws.ToString(e.Message))})
+		}
+	}
+	return failures, nil
+}
+
+// CopyOption configures Copy and Move.
+type CopyOption struct {
+	// DstBucket overrides the destination bucket; empty means the same
+	// bucket as the connection.
+	DstBucket string
+	// MetadataDirective is "COPY" (default, keep src's metadata/tags) or
+	// "REPLACE" (use Metadata below instead). Neither applies to objects
+	// above 5GiB; see Copy.
+	MetadataDirective string
+	Metadata          map[string]interface{}
+}
+
+// Copy duplicates src to dst server-side, via CopyObject for objects up to
+// 5GiB and via CreateMultipartUpload/UploadPartCopy above that, since S3
+// rejects CopyObject for larger objects. The UploadPartCopy path ignores
+// opt.MetadataDirective and opt.Metadata: the destination gets neither
+// src's metadata and tags nor opt.Metadata.
+func (c *s3Connection) Copy(src, dst *storage.File, opt CopyOption) error {
+	if c.client == nil {
+		return errors.New("s3 client not ready")
+	}
+	dstBucket := opt.DstBucket
+	if dstBucket == "" {
+		dstBucket = c.setting.Bucket
+	}
+	srcKey := objectPath(src)
+	dstKey := objectPath(dst)
+
+	head, err := c.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(c.setting.Bucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return err
+	}
+	if needsMultipartCopy(aws.ToInt64(head.ContentLength)) {
+		return c.multipartCopy(dstBucket, dstKey, srcKey, aws.ToInt64(head.ContentLength))
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(copySource(c.setting.Bucket, srcKey)),
+	}
+	directive := opt.MetadataDirective
+	if directive == "" {
+		directive = "COPY"
+	}
+	input.MetadataDirective = types.MetadataDirective(directive)
+	input.TaggingDirective = types.TaggingDirectiveCopy
+	if directive == "REPLACE" && len(opt.Metadata) > 0 {
+		md := map[string]string{}
+		for k, v := range opt.Metadata {
+			md[k] = fmt.Sprintf("%v", v)
+		}
+		input.Metadata = md
+	}
+
+	_, err = c.client.CopyObject(context.Background(), input)
+	return err
+}
+
+// multipartCopy copies an object larger than multipartCopyThreshold by
+// issuing UploadPartCopy calls over copyPartSize-sized byte ranges.
+func (c *s3Connection) multipartCopy(dstBucket, dstKey, srcKey string, size int64) error {
+	ctx := context.Background()
+	create, err := c.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(dstBucket),
+		Key:    aws.String(dstKey),
+	})
+	if err != nil {
+		return err
+	}
+	uploadID := create.UploadId
+
+	var parts []types.CompletedPart
+	partNumber := int32(1)
+	for _, r := range copyPartRanges(size) {
+		out, err := c.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(dstBucket),
+			Key:             aws.String(dstKey),
+			UploadId:        uploadID,
+			PartNumber:      aws.Int32(partNumber),
+			CopySource:      aws.String(copySource(c.setting.Bucket, srcKey)),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", r[0], r[1])),
+		})
+		if err != nil {
+			_, _ = c.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket: aws.String(dstBucket), Key: aws.String(dstKey), UploadId: uploadID,
+			})
+			return err
+		}
+		parts = append(parts, types.CompletedPart{
+			ETag:       out.CopyPartResult.ETag,
+			PartNumber: aws.Int32(partNumber),
+		})
+		partNumber++
+	}
+
+	_, err = c.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(dstBucket),
+		Key:             aws.String(dstKey),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+// Move copies src to dst and then removes src, using the same
+// cross-bucket and metadata options as Copy.
+func (c *s3Connection) Move(src, dst *storage.File, opt CopyOption) error {
+	if err := c.Copy(src, dst, opt); err != nil {
+		return err
+	}
+	return c.Remove(src, storage.RemoveOption{})
+}
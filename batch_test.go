@@ -0,0 +1,93 @@
+package storage_s3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCopySource(t *testing.T) {
+	cases := []struct {
+		bucket, key, want string
+	}{
+		{"my-bucket", "key.txt", "my-bucket/key.txt"},
+		{"my-bucket", "prefix/sub dir/key.txt", "my-bucket/prefix/sub+dir/key.txt"},
+		{"my bucket", "a/b", "my+bucket/a/b"},
+	}
+	for _, c := range cases {
+		if got := copySource(c.bucket, c.key); got != c.want {
+			t.Errorf("copySource(%q, %q) = %q, want %q", c.bucket, c.key, got, c.want)
+		}
+	}
+}
+
+func TestChunkRanges(t *testing.T) {
+	cases := []struct {
+		n, size int
+		want    [][2]int
+	}{
+		{0, 1000, nil},
+		{1, 1000, [][2]int{{0, 1}}},
+		{1000, 1000, [][2]int{{0, 1000}}},
+		{1001, 1000, [][2]int{{0, 1000}, {1000, 1001}}},
+		{2500, 1000, [][2]int{{0, 1000}, {1000, 2000}, {2000, 2500}}},
+	}
+	for _, c := range cases {
+		got := chunkRanges(c.n, c.size)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("chunkRanges(%d, %d) = %v, want %v", c.n, c.size, got, c.want)
+		}
+	}
+}
+
+func TestChunkRangesCoverAllIndicesExactlyOnce(t *testing.T) {
+	const n = 3456
+	seen := make([]bool, n)
+	for _, r := range chunkRanges(n, removeBatchMaxKeys) {
+		for i := r[0]; i < r[1]; i++ {
+			if seen[i] {
+				t.Fatalf("index %d covered by more than one range", i)
+			}
+			seen[i] = true
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Fatalf("index %d not covered by any range", i)
+		}
+	}
+}
+
+func TestNeedsMultipartCopy(t *testing.T) {
+	cases := []struct {
+		size int64
+		want bool
+	}{
+		{0, false},
+		{multipartCopyThreshold, false},
+		{multipartCopyThreshold + 1, true},
+		{multipartCopyThreshold * 2, true},
+	}
+	for _, c := range cases {
+		if got := needsMultipartCopy(c.size); got != c.want {
+			t.Errorf("needsMultipartCopy(%d) = %v, want %v", c.size, got, c.want)
+		}
+	}
+}
+
+func TestCopyPartRanges(t *testing.T) {
+	cases := []struct {
+		size int64
+		want [][2]int64
+	}{
+		{0, nil},
+		{1, [][2]int64{{0, 0}}},
+		{copyPartSize, [][2]int64{{0, copyPartSize - 1}}},
+		{copyPartSize + 1, [][2]int64{{0, copyPartSize - 1}, {copyPartSize, copyPartSize}}},
+	}
+	for _, c := range cases {
+		got := copyPartRanges(c.size)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("copyPartRanges(%d) = %v, want %v", c.size, got, c.want)
+		}
+	}
+}
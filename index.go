@@ -6,9 +6,32 @@ import (
 )
 
 func Driver() storage.Driver {
-	return &s3Driver{}
+	return &s3Driver{backend: "s3"}
+}
+
+// B2Driver returns a driver for Backblaze B2's native S3-compatible
+// endpoint: same client and connection as Driver, different setting
+// defaults and credential field names (application_key_id/application_key).
+func B2Driver() storage.Driver {
+	return &s3Driver{backend: "b2"}
+}
+
+// GCSDriver returns a driver for Google Cloud Storage's HMAC-authenticated
+// S3-compatible XML API: same client and connection as Driver, with
+// storage.googleapis.com as the default endpoint. access/secret are the
+// HMAC key pair from the GCS console, not a Google service account.
+func GCSDriver() storage.Driver {
+	return &s3Driver{backend: "gcs"}
 }
 
 func init() {
 	bamgoo.Register("s3", Driver())
+	bamgoo.Register("b2", B2Driver())
+	bamgoo.Register("gcs", GCSDriver())
+	bamgoo.Register("azblob", AzblobDriver())
+
+	// The "/s3/<bucket>/<key>" wkfs scheme (OpenWKFS, CreateWKFS, ...) has
+	// no driver of its own to register here: each s3Connection registers
+	// itself for its bucket once Open succeeds, since a WKFS needs live
+	// credentials that don't exist yet at init time.
 }
@@ -0,0 +1,320 @@
+package storage_s3
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bamgoo/storage"
+)
+
+const keyAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Key is a bucket-scoped sub-credential: a short-lived or long-lived
+// access/secret pair that is only meant to be honored for objects under
+// Prefix within the connection's bucket.
+//
+// This package only manages a Key's lifecycle and bookkeeping (Generate,
+// Enable, Disable, Reset, Delete) and enforces Prefix itself in BrowseAs;
+// it never provisions AccessKey/SecretKey with the backend. Against the
+// bundled s3/b2/gcs drivers, none of these generated credentials are
+// registered with the real service's IAM/HMAC-key API, so a presigned URL
+// signed with one will be rejected by S3/B2/GCS with InvalidAccessKeyId or
+// SignatureDoesNotMatch. Keys() is only usable end-to-end when paired with
+// a component that actually provisions the credential on the backend (or
+// a gateway in front of it that authenticates requests against this
+// KeyStore directly instead of forwarding to the backend).
+type Key struct {
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	Enabled   bool
+}
+
+// KeyStore persists a set of Keys, keyed by AccessKey. The zero value of
+// memoryKeyStore and fileKeyStore are not usable; construct them with
+// NewMemoryKeyStore / NewFileKeyStore.
+type KeyStore interface {
+	Load() (map[string]*Key, error)
+	Save(map[string]*Key) error
+}
+
+// memoryKeyStore keeps keys in process memory only; they do not survive a
+// restart. It is the default store when no KeyStore is configured.
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]*Key
+}
+
+// NewMemoryKeyStore returns a KeyStore backed by an in-memory map.
+func NewMemoryKeyStore() KeyStore {
+	return &memoryKeyStore{keys: map[string]*Key{}}
+}
+
+func (s *memoryKeyStore) Load() (map[string]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]*Key, len(s.keys))
+	for k, v := range s.keys {
+		cp := *v
+		out[k] = &cp
+	}
+	return out, nil
+}
+
+func (s *memoryKeyStore) Save(keys map[string]*Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	return nil
+}
+
+// fileKeyStore persists keys as a JSON object on disk.
+type fileKeyStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileKeyStore returns a KeyStore that reads and writes keys as JSON at path.
+func NewFileKeyStore(path string) KeyStore {
+	return &fileKeyStore{path: path}
+}
+
+func (s *fileKeyStore) Load() (map[string]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*Key{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]*Key{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *fileKeyStore) Save(keys map[string]*Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// keyManager is the access-key subsystem for a single s3Connection. It is
+// reached through s3Connection.Keys().
+type keyManager struct {
+	conn  *s3Connection
+	store KeyStore
+}
+
+// Keys returns the access-key management subinterface for this
+// connection, scoped to its bucket. It uses an in-memory KeyStore unless
+// SetKeyStore has been called.
+func (c *s3Connection) Keys() *keyManager {
+	if c.keyStore == nil {
+		c.keyStore = NewMemoryKeyStore()
+	}
+	return &keyManager{conn: c, store: c.keyStore}
+}
+
+// SetKeyStore overrides the KeyStore used by Keys(). Call it before Keys()
+// is first used if the default in-memory store is not desired.
+func (c *s3Connection) SetKeyStore(store KeyStore) {
+	c.keyStore = store
+}
+
+// Generate creates and persists a new, enabled sub-credential scoped to prefix.
+func (m *keyManager) Generate(prefix string) (*Key, error) {
+	accessKey, err := randomKey(8)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := randomKey(32)
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{AccessKey: accessKey, SecretKey: secretKey, Prefix: prefix, Enabled: true}
+
+	m.conn.keysMu.Lock()
+	defer m.conn.keysMu.Unlock()
+
+	keys, err := m.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	keys[key.AccessKey] = key
+	if err := m.store.Save(keys); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Enable re-activates a previously disabled key.
+func (m *keyManager) Enable(accessKey string) error {
+	return m.update(accessKey, func(k *Key) { k.Enabled = true })
+}
+
+// Disable deactivates a key without deleting it; BrowseAs rejects a
+// disabled key until it is enabled again.
+func (m *keyManager) Disable(accessKey string) error {
+	return m.update(accessKey, func(k *Key) { k.Enabled = false })
+}
+
+// Reset rotates the secret of an existing key, keeping its access ID and prefix.
+func (m *keyManager) Reset(accessKey string) (*Key, error) {
+	m.conn.keysMu.Lock()
+	defer m.conn.keysMu.Unlock()
+
+	keys, err := m.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[accessKey]
+	if !ok {
+		return nil, fmt.Errorf("unknown access key %q", accessKey)
+	}
+	secretKey, err := randomKey(32)
+	if err != nil {
+		return nil, err
+	}
+	key.SecretKey = secretKey
+	if err := m.store.Save(keys); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Delete removes a key permanently.
+func (m *keyManager) Delete(accessKey string) error {
+	m.conn.keysMu.Lock()
+	defer m.conn.keysMu.Unlock()
+
+	keys, err := m.store.Load()
+	if err != nil {
+		return err
+	}
+	delete(keys, accessKey)
+	return m.store.Save(keys)
+}
+
+// Get returns a single key by access ID, or an error if it does not exist.
+func (m *keyManager) Get(accessKey string) (*Key, error) {
+	m.conn.keysMu.Lock()
+	defer m.conn.keysMu.Unlock()
+
+	keys, err := m.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[accessKey]
+	if !ok {
+		return nil, fmt.Errorf("unknown access key %q", accessKey)
+	}
+	return key, nil
+}
+
+// update loads, mutates and saves a single key, with conn.keysMu held
+// across the whole sequence so concurrent Generate/Enable/Disable/Reset/
+// Delete calls on the same connection can't race and silently drop each
+// other's change.
+func (m *keyManager) update(accessKey string, fn func(*Key)) error {
+	m.conn.keysMu.Lock()
+	defer m.conn.keysMu.Unlock()
+
+	keys, err := m.store.Load()
+	if err != nil {
+		return err
+	}
+	key, ok := keys[accessKey]
+	if !ok {
+		return fmt.Errorf("unknown access key %q", accessKey)
+	}
+	fn(key)
+	return m.store.Save(keys)
+}
+
+// keyCoversPath reports whether key is scoped to cover path, i.e. path is
+// equal to or nested under key.Prefix.
+func keyCoversPath(key *Key, path string) bool {
+	if path == key.Prefix {
+		return true
+	}
+	return strings.HasPrefix(path, key.Prefix) && (key.Prefix == "" || strings.HasSuffix(key.Prefix, "/") || strings.HasPrefix(path[len(key.Prefix):], "/"))
+}
+
+func randomKey(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i, v := range b {
+		b[i] = keyAlphabet[int(v)%len(keyAlphabet)]
+	}
+	return string(b), nil
+}
+
+// BrowseAs behaves like Browse, but signs the presigned URL with the named
+// sub-key's credentials instead of the connection's root credentials, and
+// rejects any file outside that key's Prefix.
+//
+// Since the key's credentials are never provisioned with the backend (see
+// the Key doc comment), this only narrows what BrowseAs itself is willing
+// to sign; it does not make the backend enforce Prefix on its own. Callers
+// relying on Prefix as a hard security boundary need a gateway in front of
+// the backend that authenticates against this KeyStore directly.
+func (c *s3Connection) BrowseAs(file *storage.File, opt storage.BrowseOption, asKey string) (string, error) {
+	if c.client == nil {
+		return "", errors.New("s3 client not ready")
+	}
+	key, err := c.Keys().Get(asKey)
+	if err != nil {
+		return "", err
+	}
+	if !key.Enabled {
+		return "", fmt.Errorf("access key %q is disabled", asKey)
+	}
+	path := objectPath(file)
+	if !keyCoversPath(key, path) {
+		return "", fmt.Errorf("access key %q is not scoped to %q", asKey, path)
+	}
+
+	provider := credentials.NewStaticCredentialsProvider(key.AccessKey, key.SecretKey, "")
+	scopedClient := s3.New(c.client.Options(), func(o *s3.Options) {
+		o.Credentials = provider
+	})
+
+	exp := opt.Expires
+	if exp <= 0 {
+		exp = time.Hour
+	}
+	getInput := &s3.GetObjectInput{
+		Bucket: aws.String(c.setting.Bucket),
+		Key:    aws.String(path),
+	}
+	applyGetEncryption(getInput, resolveReadEncryption(c.setting, ""))
+
+	presign := s3.NewPresignClient(scopedClient)
+	out, err := presign.PresignGetObject(context.Background(), getInput, s3.WithPresignExpires(exp))
+	if err != nil {
+		return "", err
+	}
+	return out.URL, nil
+}
@@ -0,0 +1,148 @@
+package storage_s3
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRandomKey(t *testing.T) {
+	for _, n := range []int{8, 32} {
+		got, err := randomKey(n)
+		if err != nil {
+			t.Fatalf("randomKey(%d): %v", n, err)
+		}
+		if len(got) != n {
+			t.Fatalf("randomKey(%d) = %q, want length %d", n, got, n)
+		}
+		for _, c := range got {
+			if !strings.ContainsRune(keyAlphabet, c) {
+				t.Fatalf("randomKey(%d) = %q, contains char %q outside alphabet", n, got, c)
+			}
+		}
+	}
+}
+
+func newTestKeyManager() *keyManager {
+	return &keyManager{conn: &s3Connection{}, store: NewMemoryKeyStore()}
+}
+
+func TestKeyManagerLifecycle(t *testing.T) {
+	m := newTestKeyManager()
+
+	key, err := m.Generate("uploads/")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(key.AccessKey) != 8 || len(key.SecretKey) != 32 {
+		t.Fatalf("Generate returned %+v, want 8-char access key and 32-char secret", key)
+	}
+	if !key.Enabled {
+		t.Fatalf("Generate returned a disabled key: %+v", key)
+	}
+
+	if err := m.Disable(key.AccessKey); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	got, err := m.Get(key.AccessKey)
+	if err != nil {
+		t.Fatalf("Get after Disable: %v", err)
+	}
+	if got.Enabled {
+		t.Fatalf("expected key disabled after Disable, got %+v", got)
+	}
+
+	if err := m.Enable(key.AccessKey); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	got, err = m.Get(key.AccessKey)
+	if err != nil {
+		t.Fatalf("Get after Enable: %v", err)
+	}
+	if !got.Enabled {
+		t.Fatalf("expected key enabled after Enable, got %+v", got)
+	}
+
+	reset, err := m.Reset(key.AccessKey)
+	if err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if reset.SecretKey == key.SecretKey {
+		t.Fatalf("Reset did not rotate the secret")
+	}
+	if reset.AccessKey != key.AccessKey || reset.Prefix != key.Prefix {
+		t.Fatalf("Reset changed access key or prefix: %+v", reset)
+	}
+
+	if err := m.Delete(key.AccessKey); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := m.Get(key.AccessKey); err == nil {
+		t.Fatalf("expected Get to fail after Delete")
+	}
+}
+
+func TestKeyCoversPath(t *testing.T) {
+	key := &Key{Prefix: "uploads/"}
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"uploads/a.txt", true},
+		{"uploads/sub/b.txt", true},
+		{"uploads", false},
+		{"other/secret.txt", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := keyCoversPath(key, c.path); got != c.want {
+			t.Errorf("keyCoversPath(%+v, %q) = %v, want %v", key, c.path, got, c.want)
+		}
+	}
+}
+
+func TestKeyManagerUnknownKey(t *testing.T) {
+	m := newTestKeyManager()
+	if _, err := m.Get("nonexistent"); err == nil {
+		t.Fatalf("expected error for unknown access key")
+	}
+	if err := m.Enable("nonexistent"); err == nil {
+		t.Fatalf("expected error enabling unknown access key")
+	}
+	if _, err := m.Reset("nonexistent"); err == nil {
+		t.Fatalf("expected error resetting unknown access key")
+	}
+}
+
+// TestKeyManagerConcurrentGenerate exercises the race the locking fix
+// closes: concurrent Generate calls on the same connection must not
+// clobber each other's Load/Save of the shared key map.
+func TestKeyManagerConcurrentGenerate(t *testing.T) {
+	m := newTestKeyManager()
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Generate("prefix/"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	keys, err := m.store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(keys) != n {
+		t.Fatalf("expected %d keys to survive concurrent Generate, got %d", n, len(keys))
+	}
+}
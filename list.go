@@ -0,0 +1,97 @@
+package storage_s3
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bamgoo/storage"
+)
+
+// ListOption configures a single page request to List.
+type ListOption struct {
+	Delimiter         string
+	MaxKeys           int32
+	ContinuationToken string
+	StartAfter        string
+}
+
+// ListResult is one page of List results.
+type ListResult struct {
+	Files          []*storage.File
+	CommonPrefixes []string
+	NextToken      string
+	IsTruncated    bool
+}
+
+// List returns a single page of objects under prefix, wrapping
+// s3.ListObjectsV2. Pass the returned NextToken back via
+// ListOption.ContinuationToken to fetch the next page.
+func (c *s3Connection) List(prefix string, opt ListOption) (ListResult, error) {
+	if c.client == nil {
+		return ListResult{}, errors.New("s3 client not ready")
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.setting.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	if opt.Delimiter != "" {
+		input.Delimiter = aws.String(opt.Delimiter)
+	}
+	if opt.MaxKeys > 0 {
+		input.MaxKeys = aws.Int32(opt.MaxKeys)
+	}
+	if opt.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(opt.ContinuationToken)
+	}
+	if opt.StartAfter != "" {
+		input.StartAfter = aws.String(opt.StartAfter)
+	}
+
+	out, err := c.client.ListObjectsV2(context.Background(), input)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	result := ListResult{IsTruncated: aws.ToBool(out.IsTruncated)}
+	for _, obj := range out.Contents {
+		// The prefix/key/ext split that objectPath applies on the way in
+		// isn't recoverable from a raw S3 key, so listed files carry the
+		// full key verbatim and an empty prefix/ext.
+		result.Files = append(result.Files, c.instance.NewFile("", aws.ToString(obj.Key), "", aws.ToInt64(obj.Size)))
+	}
+	for _, cp := range out.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, aws.ToString(cp.Prefix))
+	}
+	if result.IsTruncated {
+		result.NextToken = aws.ToString(out.NextContinuationToken)
+	}
+	return result, nil
+}
+
+// Walk calls fn once per object under prefix, paging through the full
+// listing automatically. It stops and returns fn's error as soon as fn
+// returns a non-nil error.
+func (c *s3Connection) Walk(prefix string, fn func(*storage.File) error) error {
+	if c.client == nil {
+		return errors.New("s3 client not ready")
+	}
+	paginator := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.setting.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			file := c.instance.NewFile("", aws.ToString(obj.Key), "", aws.ToInt64(obj.Size))
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -21,12 +22,20 @@ import (
 )
 
 type (
-	s3Driver struct{}
+	// s3Driver backs the "s3", "b2" and "gcs" registrations: Backblaze B2's
+	// native S3-compatible endpoint and GCS's HMAC-authenticated
+	// S3-compatible XML API both only need different setting defaults, not
+	// a different client or connection type.
+	s3Driver struct {
+		backend string
+	}
 
 	s3Connection struct {
 		instance *storage.Instance
 		setting  s3Setting
 		client   *s3.Client
+		keyStore KeyStore
+		keysMu   sync.Mutex
 	}
 
 	s3Setting struct {
@@ -37,6 +46,14 @@ type (
 		SessionToken string
 		Endpoint     string
 		UsePathStyle bool
+
+		SSE            string
+		KMSKeyID       string
+		SSECustomerKey string
+
+		PartSize          int64
+		Concurrency       int
+		LeavePartsOnError bool
 	}
 
 	tempStream struct {
@@ -83,9 +100,46 @@ func (d *s3Driver) Connect(instance *storage.Instance) (storage.Connection, erro
 	if v, ok := instance.Setting["force_path_style"].(bool); ok {
 		setting.UsePathStyle = v
 	}
+	if v, ok := instance.Setting["sse"].(string); ok && v != "" {
+		setting.SSE = v
+	}
+	if v, ok := instance.Setting["kms_key_id"].(string); ok && v != "" {
+		setting.KMSKeyID = v
+	}
+	if v, ok := instance.Setting["sse_customer_key"].(string); ok && v != "" {
+		setting.SSECustomerKey = v
+	}
+	if v, ok := instance.Setting["part_size"].(int64); ok && v > 0 {
+		setting.PartSize = v
+	}
+	if v, ok := instance.Setting["concurrency"].(int); ok && v > 0 {
+		setting.Concurrency = v
+	}
+	if v, ok := instance.Setting["leave_parts_on_error"].(bool); ok {
+		setting.LeavePartsOnError = v
+	}
 	if setting.Bucket == "" {
 		setting.Bucket = "default"
 	}
+	switch d.backend {
+	case "b2":
+		if v, ok := instance.Setting["application_key_id"].(string); ok && v != "" {
+			setting.AccessKey = v
+		}
+		if v, ok := instance.Setting["application_key"].(string); ok && v != "" {
+			setting.SecretKey = v
+		}
+		if setting.Endpoint == "" {
+			setting.Endpoint = fmt.Sprintf("s3.%s.backblazeb2.com", setting.Region)
+		}
+	case "gcs":
+		// GCS's HMAC-authenticated XML API is S3-compatible, so this needs
+		// nothing beyond a different default endpoint: access/secret are
+		// still the HMAC access key ID/secret, read from the usual keys.
+		if setting.Endpoint == "" {
+			setting.Endpoint = "storage.googleapis.com"
+		}
+	}
 	return &s3Connection{instance: instance, setting: setting}, nil
 }
 
@@ -126,6 +180,8 @@ func (c *s3Connection) Open() error {
 			return err
 		}
 	}
+
+	RegisterWKFS(c.setting.Bucket, &s3WKFS{conn: c})
 	return nil
 }
 
@@ -142,6 +198,17 @@ func (c *s3Connection) Close() error {
 }
 
 func (c *s3Connection) Upload(original string, opt storage.UploadOption) (*storage.File, error) {
+	return c.upload(original, opt, nil)
+}
+
+// UploadWithProgress behaves like Upload, but invokes progress after every
+// chunk read from disk with the bytes read so far and the file's total
+// size, so callers can report multipart upload progress for local files.
+func (c *s3Connection) UploadWithProgress(original string, opt storage.UploadOption, progress func(bytesTransferred, total int64)) (*storage.File, error) {
+	return c.upload(original, opt, progress)
+}
+
+func (c *s3Connection) upload(original string, opt storage.UploadOption, progress func(bytesTransferred, total int64)) (*storage.File, error) {
 	if c.client == nil {
 		return nil, errors.New("s3 client not ready")
 	}
@@ -169,10 +236,30 @@ func (c *s3Connection) Upload(original string, opt storage.UploadOption) (*stora
 	}
 	defer f.Close()
 
+	var body io.Reader = f
+	if progress != nil {
+		body = &progressReader{r: f, total: st.Size(), progress: progress}
+	}
+
+	input, err := c.putObjectInput(key, body, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	uploader := manager.NewUploader(c.client, c.configureUploader)
+	if _, err := uploader.Upload(context.Background(), input); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// putObjectInput builds the PutObjectInput shared by Upload and
+// UploadStream: mimetype, expiry, encryption, metadata and tags.
+func (c *s3Connection) putObjectInput(key string, body io.Reader, opt storage.UploadOption) (*s3.PutObjectInput, error) {
 	input := &s3.PutObjectInput{
 		Bucket: aws.String(c.setting.Bucket),
 		Key:    aws.String(key),
-		Body:   f,
+		Body:   body,
 	}
 	if opt.Mimetype != "" {
 		input.ContentType = aws.String(opt.Mimetype)
@@ -180,9 +267,13 @@ func (c *s3Connection) Upload(original string, opt storage.UploadOption) (*stora
 	if !opt.Expires.IsZero() {
 		input.Expires = aws.Time(opt.Expires)
 	}
-	if len(opt.Metadata) > 0 {
+	enc, metadata := resolveEncryption(c.setting, opt.Metadata)
+	if err := applyPutEncryption(input, enc); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
 		md := map[string]string{}
-		for k, v := range opt.Metadata {
+		for k, v := range metadata {
 			md[k] = fmt.Sprintf("%v", v)
 		}
 		input.Metadata = md
@@ -200,15 +291,34 @@ func (c *s3Connection) Upload(original string, opt storage.UploadOption) (*stora
 		}
 		input.Tagging = aws.String(strings.Join(tags, "&"))
 	}
+	return input, nil
+}
 
-	_, err = c.client.PutObject(context.Background(), input)
-	if err != nil {
-		return nil, err
+// configureUploader applies the connection's part-size/concurrency tuning
+// to a manager.Uploader.
+func (c *s3Connection) configureUploader(u *manager.Uploader) {
+	if c.setting.PartSize > 0 {
+		u.PartSize = c.setting.PartSize
 	}
-	return file, nil
+	if c.setting.Concurrency > 0 {
+		u.Concurrency = c.setting.Concurrency
+	}
+	u.LeavePartsOnError = c.setting.LeavePartsOnError
 }
 
 func (c *s3Connection) Fetch(file *storage.File, opt storage.FetchOption) (storage.Stream, error) {
+	return c.fetch(file, opt, "")
+}
+
+// FetchWithKey behaves like Fetch, but overrides the SSE-C customer key
+// used to retrieve the object. Needed whenever an object was encrypted
+// with a key other than the connection-wide sse_customer_key default, the
+// static default alone can't retrieve it.
+func (c *s3Connection) FetchWithKey(file *storage.File, opt storage.FetchOption, customerKey string) (storage.Stream, error) {
+	return c.fetch(file, opt, customerKey)
+}
+
+func (c *s3Connection) fetch(file *storage.File, opt storage.FetchOption, customerKey string) (storage.Stream, error) {
 	if c.client == nil {
 		return nil, errors.New("s3 client not ready")
 	}
@@ -220,6 +330,7 @@ func (c *s3Connection) Fetch(file *storage.File, opt storage.FetchOption) (stora
 			input.Range = aws.String(fmt.Sprintf("bytes=%d-", opt.Start))
 		}
 	}
+	applyGetEncryption(input, resolveReadEncryption(c.setting, customerKey))
 	out, err := c.client.GetObject(context.Background(), input)
 	if err != nil {
 		return nil, err
@@ -244,6 +355,16 @@ func (c *s3Connection) Fetch(file *storage.File, opt storage.FetchOption) (stora
 }
 
 func (c *s3Connection) Download(file *storage.File, opt storage.DownloadOption) (string, error) {
+	return c.download(file, opt, "")
+}
+
+// DownloadWithKey behaves like Download, but overrides the SSE-C customer
+// key used to retrieve the object; see FetchWithKey.
+func (c *s3Connection) DownloadWithKey(file *storage.File, opt storage.DownloadOption, customerKey string) (string, error) {
+	return c.download(file, opt, customerKey)
+}
+
+func (c *s3Connection) download(file *storage.File, opt storage.DownloadOption, customerKey string) (string, error) {
 	if c.client == nil {
 		return "", errors.New("s3 client not ready")
 	}
@@ -262,11 +383,14 @@ func (c *s3Connection) Download(file *storage.File, opt storage.DownloadOption)
 	}
 	defer f.Close()
 
-	downloader := manager.NewDownloader(c.client)
-	_, err = downloader.Download(context.Background(), f, &s3.GetObjectInput{
+	getInput := &s3.GetObjectInput{
 		Bucket: aws.String(c.setting.Bucket),
 		Key:    aws.String(objectPath(file)),
-	})
+	}
+	applyGetEncryption(getInput, resolveReadEncryption(c.setting, customerKey))
+
+	downloader := manager.NewDownloader(c.client)
+	_, err = downloader.Download(context.Background(), f, getInput)
 	if err != nil {
 		return "", err
 	}
@@ -285,6 +409,16 @@ func (c *s3Connection) Remove(file *storage.File, _ storage.RemoveOption) error
 }
 
 func (c *s3Connection) Browse(file *storage.File, opt storage.BrowseOption) (string, error) {
+	return c.browse(file, opt, "")
+}
+
+// BrowseWithKey behaves like Browse, but overrides the SSE-C customer key
+// used to sign the presigned URL; see FetchWithKey.
+func (c *s3Connection) BrowseWithKey(file *storage.File, opt storage.BrowseOption, customerKey string) (string, error) {
+	return c.browse(file, opt, customerKey)
+}
+
+func (c *s3Connection) browse(file *storage.File, opt storage.BrowseOption, customerKey string) (string, error) {
 	if c.client == nil {
 		return "", errors.New("s3 client not ready")
 	}
@@ -292,11 +426,14 @@ func (c *s3Connection) Browse(file *storage.File, opt storage.BrowseOption) (str
 	if exp <= 0 {
 		exp = time.Hour
 	}
-	presign := s3.NewPresignClient(c.client)
-	out, err := presign.PresignGetObject(context.Background(), &s3.GetObjectInput{
+	getInput := &s3.GetObjectInput{
 		Bucket: aws.String(c.setting.Bucket),
 		Key:    aws.String(objectPath(file)),
-	}, s3.WithPresignExpires(exp))
+	}
+	applyGetEncryption(getInput, resolveReadEncryption(c.setting, customerKey))
+
+	presign := s3.NewPresignClient(c.client)
+	out, err := presign.PresignGetObject(context.Background(), getInput, s3.WithPresignExpires(exp))
 	if err != nil {
 		return "", err
 	}
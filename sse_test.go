@@ -0,0 +1,77 @@
+package storage_s3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveEncryptionDefaults(t *testing.T) {
+	setting := s3Setting{SSE: "AES256", KMSKeyID: "default-key", SSECustomerKey: "default-customer-key"}
+
+	enc, metadata := resolveEncryption(setting, nil)
+	if enc.SSE != "AES256" || enc.KMSKeyID != "default-key" || enc.CustomerKey != "default-customer-key" {
+		t.Fatalf("expected setting defaults, got %+v", enc)
+	}
+	if metadata != nil {
+		t.Fatalf("expected nil metadata passthrough, got %v", metadata)
+	}
+}
+
+func TestResolveEncryptionOverridesAndStripsReservedKeys(t *testing.T) {
+	setting := s3Setting{SSE: "AES256", SSECustomerKey: "default-customer-key"}
+	metadata := map[string]interface{}{
+		metaSSE:            "aws:kms",
+		metaSSEKMSKeyID:    "override-key",
+		metaSSECustomerKey: "override-customer-key",
+		"content-author":   "alice",
+	}
+
+	enc, clean := resolveEncryption(setting, metadata)
+	if enc.SSE != "aws:kms" {
+		t.Fatalf("expected SSE override, got %q", enc.SSE)
+	}
+	if enc.KMSKeyID != "override-key" {
+		t.Fatalf("expected KMS key override, got %q", enc.KMSKeyID)
+	}
+	if enc.CustomerKey != "override-customer-key" {
+		t.Fatalf("expected customer key override, got %q", enc.CustomerKey)
+	}
+	if want := map[string]interface{}{"content-author": "alice"}; !reflect.DeepEqual(clean, want) {
+		t.Fatalf("expected reserved keys stripped, got %v", clean)
+	}
+}
+
+func TestResolveReadEncryptionOverridesCustomerKeyOnly(t *testing.T) {
+	setting := s3Setting{SSE: "aws:kms", KMSKeyID: "default-key", SSECustomerKey: "default-customer-key"}
+
+	enc := resolveReadEncryption(setting, "per-call-key")
+	if enc.CustomerKey != "per-call-key" {
+		t.Fatalf("expected customer key override, got %q", enc.CustomerKey)
+	}
+	if enc.SSE != "aws:kms" || enc.KMSKeyID != "default-key" {
+		t.Fatalf("expected other defaults untouched, got %+v", enc)
+	}
+
+	if enc := resolveReadEncryption(setting, ""); enc.CustomerKey != "default-customer-key" {
+		t.Fatalf("expected setting default when no override given, got %q", enc.CustomerKey)
+	}
+}
+
+func TestParseEncryptionContext(t *testing.T) {
+	cases := []struct {
+		in   string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{"k=v", map[string]string{"k": "v"}},
+		{"k1=v1;k2=v2", map[string]string{"k1": "v1", "k2": "v2"}},
+		{" k1 = v1 ; ; k2=v2=withequals", map[string]string{"k1 ": " v1", "k2": "v2=withequals"}},
+		{"missing-equals;k=v", map[string]string{"k": "v"}},
+	}
+	for _, c := range cases {
+		got := parseEncryptionContext(c.in)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseEncryptionContext(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package storage_s3
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/bamgoo/storage"
+)
+
+// StreamOption extends storage.UploadOption with fields that only make
+// sense for UploadStream, where no local file is available to stat: the
+// object's size (recorded on the returned File and passed to Progress as
+// the total; the uploader does not use it) and an optional progress
+// callback reporting bytes transferred so far against total.
+type StreamOption struct {
+	storage.UploadOption
+	Size     int64
+	Ext      string
+	Progress func(bytesTransferred, total int64)
+}
+
+// UploadStream uploads data read from r directly, without first staging it
+// to a local file as Upload requires. It goes through the same
+// manager.Uploader as Upload, so large streams are still chunked and
+// uploaded in parallel.
+func (c *s3Connection) UploadStream(r io.Reader, opt StreamOption) (*storage.File, error) {
+	if c.client == nil {
+		return nil, errors.New("s3 client not ready")
+	}
+	if opt.Key == "" {
+		return nil, errors.New("missing upload key")
+	}
+
+	file := c.instance.NewFile(opt.Prefix, opt.Key, opt.Ext, opt.Size)
+	key := objectPath(file)
+
+	if opt.Progress != nil {
+		r = &progressReader{r: r, total: opt.Size, progress: opt.Progress}
+	}
+
+	input, err := c.putObjectInput(key, r, opt.UploadOption)
+	if err != nil {
+		return nil, err
+	}
+
+	uploader := manager.NewUploader(c.client, c.configureUploader)
+	if _, err := uploader.Upload(context.Background(), input); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// progressReader wraps an io.Reader, invoking progress after every Read
+// with the running byte count and the (possibly unknown, i.e. 0) total.
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	total    int64
+	progress func(bytesTransferred, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,244 @@
+package storage_s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bamgoo/storage"
+)
+
+// WKFile is the handle returned by the well-known-filesystem layer: the
+// same type is used whether it came from Open (read-only, backed by a
+// temp file holding the downloaded object) or Create (write-only, flushed
+// to S3 on Close).
+type WKFile interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	io.Seeker
+}
+
+// WKFS is the well-known-filesystem interface a backend implements so
+// paths like "/s3/<bucket>/<key>" can be opened through OpenWKFS and
+// friends instead of going through the storage.Connection API directly.
+// It follows the shape of camlistore.org/pkg/wkfs.
+type WKFS interface {
+	Open(name string) (WKFile, error)
+	Stat(name string) (os.FileInfo, error)
+	Create(name string) (WKFile, error)
+	Remove(name string) error
+	MkdirAll(name string, perm os.FileMode) error
+}
+
+var (
+	wkfsMu       sync.RWMutex
+	wkfsByBucket = map[string]WKFS{}
+)
+
+// RegisterWKFS makes fs reachable at "/s3/<bucket>/...". s3Connection.Open
+// calls this for its own bucket once it has successfully connected; it is
+// exported so other callers can register a WKFS without going through a
+// storage.Connection at all.
+func RegisterWKFS(bucket string, fs WKFS) {
+	wkfsMu.Lock()
+	defer wkfsMu.Unlock()
+	wkfsByBucket[bucket] = fs
+}
+
+// lookupWKFS splits a "/s3/<bucket>/<key>" path into its registered WKFS
+// and the key within that bucket.
+func lookupWKFS(name string) (WKFS, string, error) {
+	const prefix = "/s3/"
+	if !strings.HasPrefix(name, prefix) {
+		return nil, "", fmt.Errorf("wkfs: %q is not an /s3/ path", name)
+	}
+	rest := name[len(prefix):]
+	bucket, key, ok := strings.Cut(rest, "/")
+	if !ok {
+		return nil, "", fmt.Errorf("wkfs: %q is missing a key after the bucket", name)
+	}
+
+	wkfsMu.RLock()
+	fs, ok := wkfsByBucket[bucket]
+	wkfsMu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("wkfs: no connection registered for bucket %q", bucket)
+	}
+	return fs, key, nil
+}
+
+// OpenWKFS opens an existing object at an "/s3/<bucket>/<key>" path for reading.
+func OpenWKFS(name string) (WKFile, error) {
+	fs, key, err := lookupWKFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(key)
+}
+
+// StatWKFS returns file info for an "/s3/<bucket>/<key>" path.
+func StatWKFS(name string) (os.FileInfo, error) {
+	fs, key, err := lookupWKFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(key)
+}
+
+// CreateWKFS opens an "/s3/<bucket>/<key>" path for writing; the object is
+// created (or replaced) on S3 when the returned WKFile is closed.
+func CreateWKFS(name string) (WKFile, error) {
+	fs, key, err := lookupWKFS(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Create(key)
+}
+
+// RemoveWKFS deletes the object at an "/s3/<bucket>/<key>" path.
+func RemoveWKFS(name string) error {
+	fs, key, err := lookupWKFS(name)
+	if err != nil {
+		return err
+	}
+	return fs.Remove(key)
+}
+
+// MkdirAllWKFS is a no-op: S3 has no real directories, only key prefixes.
+func MkdirAllWKFS(name string, perm os.FileMode) error {
+	fs, key, err := lookupWKFS(name)
+	if err != nil {
+		return err
+	}
+	return fs.MkdirAll(key, perm)
+}
+
+// s3WKFS adapts an s3Connection to the WKFS interface.
+type s3WKFS struct {
+	conn *s3Connection
+}
+
+func (fs *s3WKFS) keyFile(key string) *storage.File {
+	return fs.conn.instance.NewFile("", key, "", 0)
+}
+
+func (fs *s3WKFS) Open(key string) (WKFile, error) {
+	stream, err := fs.conn.Fetch(fs.keyFile(key), storage.FetchOption{})
+	if err != nil {
+		return nil, err
+	}
+	return &wkFile{fs: fs, key: key, stream: stream}, nil
+}
+
+func (fs *s3WKFS) Stat(key string) (os.FileInfo, error) {
+	if fs.conn.client == nil {
+		return nil, errors.New("s3 client not ready")
+	}
+	out, err := fs.conn.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(fs.conn.setting.Bucket),
+		Key:    aws.String(objectPath(fs.keyFile(key))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wkFileInfo{
+		name:    key,
+		size:    aws.ToInt64(out.ContentLength),
+		modTime: aws.ToTime(out.LastModified),
+	}, nil
+}
+
+func (fs *s3WKFS) Create(key string) (WKFile, error) {
+	tmp, err := os.CreateTemp("", "bamgoo-storage-s3-wkfs-*")
+	if err != nil {
+		return nil, err
+	}
+	return &wkFile{fs: fs, key: key, tmp: tmp, writable: true}, nil
+}
+
+func (fs *s3WKFS) Remove(key string) error {
+	return fs.conn.Remove(fs.keyFile(key), storage.RemoveOption{})
+}
+
+func (fs *s3WKFS) MkdirAll(string, os.FileMode) error {
+	return nil
+}
+
+// wkFile is the WKFile returned by both Open (read-only, stream backed)
+// and Create (write-only, buffered to a temp file and uploaded on Close).
+type wkFile struct {
+	fs       *s3WKFS
+	key      string
+	stream   storage.Stream // set when opened for reading
+	tmp      *os.File       // set when opened for writing
+	writable bool
+}
+
+func (f *wkFile) Read(p []byte) (int, error) {
+	if f.writable {
+		return 0, errors.New("wkfs: file opened for writing is not readable")
+	}
+	return f.stream.Read(p)
+}
+
+func (f *wkFile) Write(p []byte) (int, error) {
+	if !f.writable {
+		return 0, errors.New("wkfs: file opened for reading is not writable")
+	}
+	return f.tmp.Write(p)
+}
+
+func (f *wkFile) Seek(offset int64, whence int) (int64, error) {
+	if f.writable {
+		return f.tmp.Seek(offset, whence)
+	}
+	return f.stream.Seek(offset, whence)
+}
+
+func (f *wkFile) Close() error {
+	if !f.writable {
+		return f.stream.Close()
+	}
+
+	st, err := f.tmp.Stat()
+	if err != nil {
+		_ = f.tmp.Close()
+		_ = os.Remove(f.tmp.Name())
+		return err
+	}
+	if _, err := f.tmp.Seek(0, 0); err != nil {
+		_ = f.tmp.Close()
+		_ = os.Remove(f.tmp.Name())
+		return err
+	}
+
+	_, err = f.fs.conn.UploadStream(f.tmp, StreamOption{
+		UploadOption: storage.UploadOption{Key: f.key},
+		Size:         st.Size(),
+	})
+	_ = f.tmp.Close()
+	_ = os.Remove(f.tmp.Name())
+	return err
+}
+
+// wkFileInfo is a minimal os.FileInfo for an S3 object.
+type wkFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i *wkFileInfo) Name() string       { return i.name }
+func (i *wkFileInfo) Size() int64        { return i.size }
+func (i *wkFileInfo) Mode() os.FileMode  { return 0o644 }
+func (i *wkFileInfo) ModTime() time.Time { return i.modTime }
+func (i *wkFileInfo) IsDir() bool        { return false }
+func (i *wkFileInfo) Sys() interface{}   { return nil }